@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// accountRegion is a single account/region pair to sweep, along with the
+// aws.Config to use when calling into it.
+type accountRegion struct {
+	AccountID string
+	Region    string
+	Cfg       aws.Config
+}
+
+// resolveAccountRegions expands cleanupCfg into one accountRegion per
+// (target, region) pair, assuming target.RoleARN via STS using defaultCfg's
+// credentials. If cleanupCfg has no targets, defaultCfg is used as-is for a
+// single, unlabelled sweep, so the tool keeps working without a --config
+// file.
+func resolveAccountRegions(ctx context.Context, defaultCfg aws.Config, cleanupCfg cleanupConfig) (result []accountRegion, err error) {
+	if len(cleanupCfg.Targets) == 0 {
+		return []accountRegion{{Cfg: defaultCfg}}, nil
+	}
+
+	for _, t := range cleanupCfg.Targets {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(defaultCfg), t.RoleARN)
+		creds := aws.NewCredentialsCache(provider)
+		for _, region := range t.Regions {
+			var regionCfg aws.Config
+			regionCfg, err = config.LoadDefaultConfig(ctx,
+				config.WithRegion(region),
+				config.WithCredentialsProvider(creds),
+			)
+			if err != nil {
+				err = fmt.Errorf("failed to build config for account %q region %q: %w", t.AccountID, region, err)
+				return
+			}
+			result = append(result, accountRegion{AccountID: t.AccountID, Region: region, Cfg: regionCfg})
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// consumer describes a single container image reference found on a resource
+// that might be consuming an image from one of our ECR repositories.
+type consumer struct {
+	ResourceName string
+	ResourceARN  string
+	Image        string
+}
+
+// Discoverer finds images that are in use by some AWS resource, so that
+// those images can be excluded from deletion.
+type Discoverer interface {
+	// Name identifies the discoverer, and is the value used with --source to
+	// select it.
+	Name() string
+	Discover(ctx context.Context, cfg aws.Config) ([]consumer, error)
+}
+
+var allDiscoverers = []Discoverer{
+	ecsDiscoverer{},
+	lambdaDiscoverer{},
+	eksDiscoverer{},
+	batchDiscoverer{},
+	codebuildDiscoverer{},
+	apprunnerDiscoverer{},
+	sagemakerDiscoverer{},
+}
+
+// discoverersByName returns the subset of allDiscoverers named in value, a
+// comma-separated list such as "ecs,lambda". An empty value selects all of
+// them.
+func discoverersByName(value string) (result []Discoverer, err error) {
+	byName := make(map[string]Discoverer, len(allDiscoverers))
+	for _, d := range allDiscoverers {
+		byName[d.Name()] = d
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return allDiscoverers, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		d, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q", name)
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
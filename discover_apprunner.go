@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+)
+
+type apprunnerDiscoverer struct{}
+
+func (apprunnerDiscoverer) Name() string { return "apprunner" }
+
+func (apprunnerDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	apprunnerService := apprunner.NewFromConfig(cfg)
+
+	p := apprunner.NewListServicesPaginator(apprunnerService, &apprunner.ListServicesInput{})
+	for p.HasMorePages() {
+		var op *apprunner.ListServicesOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list services: %w", err)
+			return
+		}
+
+		for _, svc := range op.ServiceSummaryList {
+			var dso *apprunner.DescribeServiceOutput
+			dso, err = apprunnerService.DescribeService(ctx, &apprunner.DescribeServiceInput{ServiceArn: svc.ServiceArn})
+			if err != nil {
+				err = fmt.Errorf("failed to describe service %q: %w", *svc.ServiceArn, err)
+				return
+			}
+
+			imageRepo := dso.Service.SourceConfiguration.ImageRepository
+			if imageRepo == nil {
+				continue
+			}
+			consumers = append(consumers, consumer{
+				ResourceName: *svc.ServiceName,
+				ResourceARN:  *svc.ServiceArn,
+				Image:        *imageRepo.ImageIdentifier,
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/batch"
+)
+
+type batchDiscoverer struct{}
+
+func (batchDiscoverer) Name() string { return "batch" }
+
+func (batchDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	batchService := batch.NewFromConfig(cfg)
+
+	status := "ACTIVE"
+	var nextToken *string
+	for {
+		var op *batch.DescribeJobDefinitionsOutput
+		op, err = batchService.DescribeJobDefinitions(ctx, &batch.DescribeJobDefinitionsInput{
+			Status:    &status,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to describe job definitions: %w", err)
+			return
+		}
+
+		for _, jd := range op.JobDefinitions {
+			if jd.ContainerProperties == nil || jd.ContainerProperties.Image == nil {
+				continue
+			}
+			consumers = append(consumers, consumer{
+				ResourceName: *jd.JobDefinitionName,
+				ResourceARN:  *jd.JobDefinitionArn,
+				Image:        *jd.ContainerProperties.Image,
+			})
+		}
+
+		if op.NextToken == nil {
+			break
+		}
+		nextToken = op.NextToken
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/a-h/pager"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codebuild"
+)
+
+type codebuildDiscoverer struct{}
+
+func (codebuildDiscoverer) Name() string { return "codebuild" }
+
+func (codebuildDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	codebuildService := codebuild.NewFromConfig(cfg)
+
+	var names []string
+	p := codebuild.NewListProjectsPaginator(codebuildService, &codebuild.ListProjectsInput{})
+	for p.HasMorePages() {
+		var op *codebuild.ListProjectsOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list projects: %w", err)
+			return
+		}
+		names = append(names, op.Projects...)
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	// BatchGetProjects accepts up to 100 names per call.
+	for namesPage := range pager.Channel(names, 100) {
+		var op *codebuild.BatchGetProjectsOutput
+		op, err = codebuildService.BatchGetProjects(ctx, &codebuild.BatchGetProjectsInput{Names: namesPage})
+		if err != nil {
+			err = fmt.Errorf("failed to get projects: %w", err)
+			return
+		}
+		for _, p := range op.Projects {
+			if p.Environment == nil || p.Environment.Image == nil {
+				continue
+			}
+			consumers = append(consumers, consumer{
+				ResourceName: *p.Name,
+				ResourceARN:  *p.Arn,
+				Image:        *p.Environment.Image,
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+type ecsDiscoverer struct{}
+
+func (ecsDiscoverer) Name() string { return "ecs" }
+
+func (ecsDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	ecsService := ecs.NewFromConfig(cfg)
+
+	p := ecs.NewListTaskDefinitionsPaginator(ecsService, &ecs.ListTaskDefinitionsInput{})
+	for p.HasMorePages() {
+		var op *ecs.ListTaskDefinitionsOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list task definitions: %w", err)
+			return
+		}
+
+		for _, arn := range op.TaskDefinitionArns {
+			output, err := ecsService.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: &arn})
+			if err != nil {
+				return nil, err
+			}
+			for _, containerDef := range output.TaskDefinition.ContainerDefinitions {
+				consumers = append(consumers, consumer{
+					ResourceName: fmt.Sprintf("%s/%s", arn, *containerDef.Name),
+					ResourceARN:  arn,
+					Image:        *containerDef.Image,
+				})
+			}
+		}
+	}
+
+	return
+}
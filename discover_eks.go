@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	v1aws "github.com/aws/aws-sdk-go/aws"
+	v1credentials "github.com/aws/aws-sdk-go/aws/credentials"
+	v1session "github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/aws-iam-authenticator/pkg/token"
+)
+
+type eksDiscoverer struct{}
+
+func (eksDiscoverer) Name() string { return "eks" }
+
+func (eksDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	eksService := eks.NewFromConfig(cfg)
+
+	p := eks.NewListClustersPaginator(eksService, &eks.ListClustersInput{})
+	for p.HasMorePages() {
+		var op *eks.ListClustersOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list clusters: %w", err)
+			return
+		}
+		for _, clusterName := range op.Clusters {
+			var clusterConsumers []consumer
+			clusterConsumers, err = getInUseImagesForCluster(ctx, eksService, cfg, clusterName)
+			if err != nil {
+				err = fmt.Errorf("failed to discover pods for cluster %q: %w", clusterName, err)
+				return
+			}
+			consumers = append(consumers, clusterConsumers...)
+		}
+	}
+
+	return
+}
+
+func getInUseImagesForCluster(ctx context.Context, eksService *eks.Client, cfg aws.Config, clusterName string) (consumers []consumer, err error) {
+	dco, err := eksService.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: &clusterName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+
+	restConfig, err := restConfigForCluster(ctx, cfg, clusterName, *dco.Cluster.Endpoint, *dco.Cluster.CertificateAuthority.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+		containers = append(containers, pod.Spec.InitContainers...)
+		containers = append(containers, pod.Spec.Containers...)
+		for _, c := range containers {
+			consumers = append(consumers, consumer{
+				ResourceName: fmt.Sprintf("%s/%s/%s/%s", clusterName, pod.Namespace, pod.Name, c.Name),
+				ResourceARN:  *dco.Cluster.Arn,
+				Image:        c.Image,
+			})
+		}
+	}
+
+	return
+}
+
+// restConfigForCluster builds a Kubernetes client config for an EKS cluster
+// using an IAM authenticator token in place of the "aws eks get-token" exec
+// plugin, so that no subprocess or kubeconfig file is required. The token is
+// generated from cfg's own credentials rather than the ambient default
+// chain, so that a per-target assumed role (see resolveAccountRegions) is
+// what authenticates to the cluster, not whatever identity happens to be
+// active locally.
+func restConfigForCluster(ctx context.Context, cfg aws.Config, clusterName, endpoint, caDataBase64 string) (*rest.Config, error) {
+	caData, err := base64.StdEncoding.DecodeString(caDataBase64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate authority data: %w", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	sess, err := v1session.NewSession(&v1aws.Config{
+		Region:      v1aws.String(cfg.Region),
+		Credentials: v1credentials.NewStaticCredentials(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	gen, err := token.NewGenerator(false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token generator: %w", err)
+	}
+	tok, err := gen.GetWithSTS(clusterName, sts.New(sess))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return &rest.Config{
+		Host:        endpoint,
+		BearerToken: tok.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: caData,
+		},
+	}, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+type lambdaDiscoverer struct{}
+
+func (lambdaDiscoverer) Name() string { return "lambda" }
+
+func (lambdaDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	lambdaService := lambda.NewFromConfig(cfg)
+
+	p := lambda.NewListFunctionsPaginator(lambdaService, &lambda.ListFunctionsInput{})
+	for p.HasMorePages() {
+		var op *lambda.ListFunctionsOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list functions: %w", err)
+			return
+		}
+		for _, f := range op.Functions {
+			if string(f.PackageType) != "Image" {
+				continue
+			}
+			var gfo *lambda.GetFunctionOutput
+			gfo, err = lambdaService.GetFunction(ctx, &lambda.GetFunctionInput{
+				FunctionName: f.FunctionName,
+			})
+			if err != nil {
+				err = fmt.Errorf("failed to get function %q: %w", *f.FunctionName, err)
+				return
+			}
+			consumers = append(consumers, consumer{
+				ResourceName: *f.FunctionName,
+				ResourceARN:  *f.FunctionArn,
+				Image:        *gfo.Code.ImageUri,
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sagemaker"
+)
+
+type sagemakerDiscoverer struct{}
+
+func (sagemakerDiscoverer) Name() string { return "sagemaker" }
+
+func (sagemakerDiscoverer) Discover(ctx context.Context, cfg aws.Config) (consumers []consumer, err error) {
+	sagemakerService := sagemaker.NewFromConfig(cfg)
+
+	p := sagemaker.NewListModelsPaginator(sagemakerService, &sagemaker.ListModelsInput{})
+	for p.HasMorePages() {
+		var op *sagemaker.ListModelsOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to list models: %w", err)
+			return
+		}
+
+		for _, m := range op.Models {
+			var dmo *sagemaker.DescribeModelOutput
+			dmo, err = sagemakerService.DescribeModel(ctx, &sagemaker.DescribeModelInput{ModelName: m.ModelName})
+			if err != nil {
+				err = fmt.Errorf("failed to describe model %q: %w", *m.ModelName, err)
+				return
+			}
+
+			if dmo.PrimaryContainer == nil || dmo.PrimaryContainer.Image == nil {
+				continue
+			}
+			consumers = append(consumers, consumer{
+				ResourceName: *dmo.ModelName,
+				ResourceARN:  *dmo.ModelArn,
+				Image:        *dmo.PrimaryContainer.Image,
+			})
+		}
+	}
+
+	return
+}
@@ -4,122 +4,508 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/a-h/pager"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
-	"github.com/aws/aws-sdk-go-v2/service/ecs"
-	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
-var flagDryRun = flag.Bool("dryrun", true, "Set to false to run the deletion.")
+var (
+	flagDryRun                = flag.Bool("dryrun", true, "Set to false to run the deletion.")
+	flagKeep                  = flag.Int("keep", 0, "Number of most recently pushed images to keep per repository, regardless of use.")
+	flagMinAge                = flag.Duration("min-age", 0, "Images pushed more recently than this are never deleted, e.g. 72h.")
+	flagSource                = flag.String("source", strings.Join(discovererNames(allDiscoverers), ","), "Comma-separated list of in-use image discovery sources to run.")
+	flagPruneUntagged         = flag.Bool("prune-untagged", false, "Also delete untagged manifests left behind by overwritten tags.")
+	flagConfig                = flag.String("config", "", "Path to a YAML/JSON config file listing the accounts, roles, and regions to sweep. If unset, the default SDK config is used for a single account/region.")
+	flagMaxConcurrentAccounts = flag.Int("max-concurrent-accounts", 4, "Maximum number of account/region targets to sweep concurrently.")
+	flagOutput                = flag.String("output", "text", "Report format: text, json, or csv.")
+	flagReportFile            = flag.String("report-file", "", "Path to write the report to. Defaults to stdout.")
+	flagLogLevel              = flag.String("log-level", "info", "Log level: debug, info, warn, or error.")
+	flagLogFormat             = flag.String("log-format", "console", "Log format: console or json.")
+	flagExcludeTag            patternListFlag
+	flagProtectTag            patternListFlag
+)
+
+func init() {
+	flag.Var(&flagExcludeTag, "exclude-tag", "Regex matching tags to exclude from deletion. Repeatable.")
+	flag.Var(&flagProtectTag, "protect-tag", "Regex matching tags to protect from deletion, e.g. ^release-. Repeatable.")
+}
+
+func discovererNames(ds []Discoverer) (names []string) {
+	for _, d := range ds {
+		names = append(names, d.Name())
+	}
+	return
+}
+
+// patternListFlag collects repeated regex flag values into a compiled pattern list.
+type patternListFlag []*regexp.Regexp
+
+func (p *patternListFlag) String() string {
+	s := make([]string, len(*p))
+	for i, re := range *p {
+		s[i] = re.String()
+	}
+	return fmt.Sprint(s)
+}
+
+func (p *patternListFlag) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", value, err)
+	}
+	*p = append(*p, re)
+	return nil
+}
 
 func main() {
 	flag.Parse()
 
-	err := run(context.Background(), *flagDryRun)
+	logger, err := newLogger(*flagLogLevel, *flagLogFormat)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	defer logger.Sync()
+
+	policy := retentionPolicy{
+		Keep:       *flagKeep,
+		MinAge:     *flagMinAge,
+		ExcludeTag: flagExcludeTag,
+		ProtectTag: flagProtectTag,
+	}
+
+	discoverers, err := discoverersByName(*flagSource)
+	if err != nil {
+		logger.Error("invalid --source", zap.Error(err))
+		os.Exit(1)
+	}
+
+	var cleanupCfg cleanupConfig
+	if *flagConfig != "" {
+		cleanupCfg, err = loadConfig(*flagConfig)
+		if err != nil {
+			logger.Error("invalid --config", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	err = run(context.Background(), *flagDryRun, policy, discoverers, *flagPruneUntagged, cleanupCfg, *flagMaxConcurrentAccounts, logger, outputOptions{Format: *flagOutput, ReportFile: *flagReportFile})
+	if err != nil {
+		logger.Error("run failed", zap.Error(err))
+		os.Exit(1)
+	}
 }
 
-func run(ctx context.Context, dryRun bool) (err error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+// outputOptions controls where and in what format the run's report is
+// written.
+type outputOptions struct {
+	Format     string
+	ReportFile string
+}
+
+// retentionPolicy controls which images that are otherwise unused still get
+// kept around, e.g. because they're recent, explicitly protected, or within
+// the newest N pushed to a repository.
+type retentionPolicy struct {
+	Keep       int
+	MinAge     time.Duration
+	ExcludeTag []*regexp.Regexp
+	ProtectTag []*regexp.Regexp
+}
+
+func matchesAny(tags []string, patterns []*regexp.Regexp) bool {
+	for _, tag := range tags {
+		for _, p := range patterns {
+			if p.MatchString(tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pendingDeletion is the set of image identifiers to delete from a single
+// repository, along with the repo itself so the deletion pass knows which
+// account/region's ECR API to call.
+type pendingDeletion struct {
+	Repo     repo
+	ImageIDs []types.ImageIdentifier
+}
+
+// run sweeps every configured account/region, builds a single global view
+// of in-use and unused images across all of them, and then applies the
+// retention policy and (optionally) deletes unused images per repository.
+// Discovery must span every account because a consumer in one account (e.g.
+// an ECS task) may reference an ECR repo in another, so an image can only be
+// judged unused once every account has reported in. Diagnostics go through
+// logger; a structured report of what was found and done is written per
+// output.
+func run(ctx context.Context, dryRun bool, policy retentionPolicy, discoverers []Discoverer, pruneUntagged bool, cleanupCfg cleanupConfig, maxConcurrentAccounts int, logger *zap.Logger, output outputOptions) (err error) {
+	defaultCfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		err = fmt.Errorf("unable to load SDK config: %w", err)
 		return
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(3)
+	accountRegions, err := resolveAccountRegions(ctx, defaultCfg, cleanupCfg)
+	if err != nil {
+		return
+	}
+
 	var allImages []image
-	var inUseImagesECS []inUseImageECS
-	var inUseImagesLambda []inUseImageLambda
-	var allImagesErr, inUseImagesECSErr, inUseImagesLambdaErr error
-	go func() {
-		defer wg.Done()
-		allImages, allImagesErr = getAllImages(ctx, cfg)
-	}()
-	go func() {
-		defer wg.Done()
-		inUseImagesECS, inUseImagesECSErr = getInUseImages(ctx, cfg)
-	}()
-	go func() {
-		defer wg.Done()
-		inUseImagesLambda, inUseImagesLambdaErr = getInUseImagesLambda(ctx, cfg)
-	}()
-	wg.Wait()
-	err = multierr.Combine(allImagesErr, inUseImagesECSErr, inUseImagesLambdaErr)
+	var mu sync.Mutex
+	consumersByDiscoverer := make(map[string][]consumer, len(discoverers))
+	var discoverErrs error
+
+	// g gates the number of account/region targets swept concurrently, as
+	// --max-concurrent-accounts documents. Each target's own image listing
+	// and discoverers run together, unthrottled, inside that target's slot.
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentAccounts)
+	for _, ar := range accountRegions {
+		ar := ar
+		g.Go(func() error {
+			targetGroup, targetCtx := errgroup.WithContext(gctx)
+			targetGroup.Go(func() error {
+				images, imagesErr := getAllImages(targetCtx, ar.Cfg, pruneUntagged)
+				if imagesErr != nil {
+					return fmt.Errorf("%s/%s: %w", ar.AccountID, ar.Region, imagesErr)
+				}
+				mu.Lock()
+				allImages = append(allImages, images...)
+				mu.Unlock()
+				return nil
+			})
+			for _, d := range discoverers {
+				d := d
+				targetGroup.Go(func() error {
+					found, derr := d.Discover(targetCtx, ar.Cfg)
+					mu.Lock()
+					defer mu.Unlock()
+					if derr != nil {
+						discoverErrs = multierr.Append(discoverErrs, fmt.Errorf("%s (%s/%s): %w", d.Name(), ar.AccountID, ar.Region, derr))
+						return nil
+					}
+					consumersByDiscoverer[d.Name()] = append(consumersByDiscoverer[d.Name()], found...)
+					return nil
+				})
+			}
+			return targetGroup.Wait()
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return
+	}
+	err = multierr.Combine(discoverErrs)
 	if err != nil {
 		return
 	}
 
-	inUseImagesByContainerMap := map[string]struct{}{}
-	fmt.Printf("Images in use (ECS):\n")
-	for _, img := range inUseImagesECS {
-		fmt.Printf("  %v %v\n", img.ImageName, img.Image)
-		inUseImagesByContainerMap[img.Image] = struct{}{}
+	inUseByImage := map[string][]inUseBy{}
+	for _, d := range discoverers {
+		for _, c := range consumersByDiscoverer[d.Name()] {
+			logger.Info("image in use", zap.String("source", d.Name()), zap.String("resource", c.ResourceName), zap.String("image", c.Image))
+			inUseByImage[c.Image] = append(inUseByImage[c.Image], inUseBy{Service: d.Name(), ResourceARN: c.ResourceARN})
+		}
 	}
-	fmt.Printf("Images in use (Lambda):\n")
-	for _, img := range inUseImagesLambda {
-		fmt.Printf("  %v %v\n", img.FunctionName, img.Container)
-		inUseImagesByContainerMap[img.Container] = struct{}{}
+
+	reposByURI := map[string]repo{}
+	unusedImagesByRepoURI := map[string][]image{}
+	repoImageCounts := map[string]int{}
+	repoInUseCounts := map[string]int{}
+	var imageReports []imageReport
+	for _, img := range allImages {
+		reposByURI[img.Repo.URI] = img.Repo
+		repoImageCounts[img.Repo.URI]++
+
+		consumers := inUseConsumersForImage(img, inUseByImage)
+		if len(consumers) > 0 {
+			repoInUseCounts[img.Repo.URI]++
+			imageReports = append(imageReports, imageReport{URI: img.URI, Tag: img.Tag, Digest: img.Digest, InUseBy: consumers, Action: actionInUse})
+			continue
+		}
+
+		logger.Info("image not in use", zap.String("image", img.URI))
+		unusedImagesByRepoURI[img.Repo.URI] = append(unusedImagesByRepoURI[img.Repo.URI], img)
 	}
 
-	repoNames := map[string]struct{}{}
-	unusedImagesByRepoName := map[string][]image{}
-	var unusedImageCount int
-	fmt.Printf("Images that aren't used in ECS:\n")
+	repoDigests := map[string]map[string]struct{}{}
 	for _, img := range allImages {
-		if _, ok := inUseImagesByContainerMap[img.URI]; !ok {
-			repoNames[img.Repo.Name] = struct{}{}
-			unusedImagesByRepoName[img.Repo.Name] = append(unusedImagesByRepoName[img.Repo.Name], img)
-			unusedImageCount++
-			fmt.Printf("  %v\n", img.URI)
+		if img.Digest == "" {
+			continue
+		}
+		if repoDigests[img.Repo.URI] == nil {
+			repoDigests[img.Repo.URI] = map[string]struct{}{}
+		}
+		repoDigests[img.Repo.URI][img.Digest] = struct{}{}
+	}
+
+	now := time.Now()
+	toDeleteByRepoURI := map[string]pendingDeletion{}
+	repoErrorCounts := map[string]int{}
+	var toDeleteCount int
+	for repoURI, r := range reposByURI {
+		unusedImages := unusedImagesByRepoURI[repoURI]
+		if len(unusedImages) == 0 {
+			continue
+		}
+		ecrService := ecr.NewFromConfig(r.Cfg)
+
+		var imageIDs []types.ImageIdentifier
+		var deletedDigests map[string]struct{}
+		var policyReports []imageReport
+		imageIDs, deletedDigests, policyReports, err = applyRetentionPolicy(ctx, ecrService, r.Name, unusedImages, policy, now, dryRun, logger)
+		if err != nil {
+			err = fmt.Errorf("failed to apply retention policy to %q: %w", repoURI, err)
+			return
+		}
+		imageReports = append(imageReports, policyReports...)
+
+		survivingDigests := map[string]struct{}{}
+		for digest := range repoDigests[repoURI] {
+			if _, deleting := deletedDigests[digest]; !deleting {
+				survivingDigests[digest] = struct{}{}
+			}
+		}
+		imageIDs, err = applyManifestAwareness(ctx, ecrService, r.Name, imageIDs, survivingDigests, dryRun, logger)
+		if err != nil {
+			err = fmt.Errorf("failed to apply manifest awareness to %q: %w", repoURI, err)
+			return
 		}
+
+		toDeleteByRepoURI[repoURI] = pendingDeletion{Repo: r, ImageIDs: imageIDs}
+		toDeleteCount += len(imageIDs)
 	}
 
 	if !dryRun {
-		fmt.Printf("Deleting %d unused images...\n", unusedImageCount)
-		for repoName := range repoNames {
-			unusedImages := unusedImagesByRepoName[repoName]
-			if len(unusedImages) == 0 {
+		logger.Info("deleting unused images", zap.Int("count", toDeleteCount))
+		for repoURI, pending := range toDeleteByRepoURI {
+			if len(pending.ImageIDs) == 0 {
 				continue
 			}
-			fmt.Printf("  %s - deleting %d tags...\n", repoName, len(unusedImages))
-			tags := make([]string, len(unusedImages))
-			for i := 0; i < len(unusedImages); i++ {
-				tags[i] = unusedImages[i].Tag
-			}
-			// Run 100 tags at a time.
-			for tagPage := range pager.Channel(tags, 100) {
-				fmt.Printf("    deleting batch of %d tags...\n", len(tagPage))
-				err = deleteImages(ctx, cfg, repoName, tagPage)
-				if err != nil {
-					err = fmt.Errorf("failed to delete image tags: %w", err)
+			logger.Info("deleting images from repository", zap.String("repository", repoURI), zap.Int("count", len(pending.ImageIDs)))
+			// Run 100 images at a time.
+			for imageIDPage := range pager.Channel(pending.ImageIDs, 100) {
+				logger.Info("deleting batch", zap.Int("count", len(imageIDPage)))
+				deleteErr := deleteImages(ctx, pending.Repo.Cfg, pending.Repo.Name, imageIDPage)
+				if deleteErr != nil {
+					err = fmt.Errorf("failed to delete images: %w", deleteErr)
+					repoErrorCounts[repoURI]++
 				}
 			}
 		}
-		fmt.Printf("Deleted %d unused images.\n", unusedImageCount)
+		logger.Info("deleted unused images", zap.Int("count", toDeleteCount))
+	} else {
+		logger.Info("would delete unused images", zap.Int("count", toDeleteCount))
 	}
 
-	fmt.Println()
+	rep := report{DryRun: dryRun, ScannedAt: now, Images: imageReports}
+	for repoURI, r := range reposByURI {
+		rep.Repositories = append(rep.Repositories, repositoryReport{
+			Name:        r.Name,
+			TotalImages: repoImageCounts[repoURI],
+			InUse:       repoInUseCounts[repoURI],
+			Unused:      len(unusedImagesByRepoURI[repoURI]),
+			Deleted:     len(toDeleteByRepoURI[repoURI].ImageIDs),
+			Errors:      repoErrorCounts[repoURI],
+		})
+	}
+
+	reportErr := emitReport(rep, output)
+	if reportErr != nil && err == nil {
+		err = reportErr
+	}
 
 	return err
 }
 
-func deleteImages(ctx context.Context, cfg aws.Config, repoName string, tags []string) (err error) {
-	imageIDs := make([]types.ImageIdentifier, len(tags))
-	for i := 0; i < len(tags); i++ {
-		imageIDs[i] = types.ImageIdentifier{ImageTag: &tags[i]}
+// emitReport writes rep to output.ReportFile (or stdout, if unset) in
+// output.Format.
+func emitReport(rep report, output outputOptions) error {
+	w := io.Writer(os.Stdout)
+	if output.ReportFile != "" {
+		f, err := os.Create(output.ReportFile)
+		if err != nil {
+			return fmt.Errorf("failed to create report file %q: %w", output.ReportFile, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeReport(w, output.Format, rep)
+}
+
+// applyRetentionPolicy describes the candidate images to get their push
+// times and full tag sets, then decides which are actually safe to delete:
+// images with an unknown push time are always kept, since we can't tell
+// whether they're within policy.MinAge or among the newest policy.Keep;
+// protected and excluded tags are kept, images younger than policy.MinAge
+// are kept, and the newest policy.Keep remaining images (by ImagePushedAt)
+// are kept regardless of anything else. A digest's ImageDetail lists every
+// tag currently pointing at it, which can include tags that were never
+// candidates because a consumer references them; only the subset that were
+// actually classified as unused candidates is ever queued for deletion, so
+// an in-use alias of a digest is never untagged as a side effect of
+// deleting an unused one. Untagged manifests are deleted by digest;
+// everything else is deleted by tag. entries reports the fate of every
+// candidate, for inclusion in the run's report.
+func applyRetentionPolicy(ctx context.Context, svc ecr.DescribeImagesAPIClient, repoName string, candidates []image, policy retentionPolicy, now time.Time, dryRun bool, logger *zap.Logger) (toDelete []types.ImageIdentifier, deletedDigests map[string]struct{}, entries []imageReport, err error) {
+	deletedDigests = map[string]struct{}{}
+	imageIDs := make([]types.ImageIdentifier, len(candidates))
+	byDigest := map[string]image{}
+	byTag := map[string]image{}
+	for i, c := range candidates {
+		imageIDs[i] = c.imageIdentifier()
+		if c.Digest != "" {
+			byDigest[c.Digest] = c
+		}
+		if c.Tag != "" {
+			byTag[c.Tag] = c
+		}
+	}
+	lookup := func(d types.ImageDetail) image {
+		if d.ImageDigest != nil {
+			if c, ok := byDigest[*d.ImageDigest]; ok {
+				return c
+			}
+		}
+		for _, tag := range d.ImageTags {
+			if c, ok := byTag[tag]; ok {
+				return c
+			}
+		}
+		return image{}
+	}
+
+	details, err := describeImages(ctx, svc, repoName, imageIDs)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to describe images: %w", err)
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].ImagePushedAt == nil {
+			return false
+		}
+		if details[j].ImagePushedAt == nil {
+			return true
+		}
+		return details[i].ImagePushedAt.After(*details[j].ImagePushedAt)
+	})
+
+	deleteAction := actionWouldDelete
+	if !dryRun {
+		deleteAction = actionDeleted
+	}
+
+	var kept int
+	for _, d := range details {
+		c := lookup(d)
+		entry := imageReport{URI: c.URI, Tag: c.Tag, Digest: c.Digest}
+		if d.ImagePushedAt != nil {
+			entry.PushedAt = d.ImagePushedAt
+		}
+		if d.ImageSizeInBytes != nil {
+			entry.SizeBytes = *d.ImageSizeInBytes
+		}
+
+		if d.ImagePushedAt == nil {
+			logger.Info("keeping image", zap.String("repository", repoName), zap.Strings("tags", d.ImageTags), zap.String("reason", "unknown push time"))
+			entry.Action = actionKept
+			entries = append(entries, entry)
+			continue
+		}
+
+		if matchesAny(d.ImageTags, policy.ProtectTag) || matchesAny(d.ImageTags, policy.ExcludeTag) {
+			logger.Info("keeping image", zap.String("repository", repoName), zap.Strings("tags", d.ImageTags), zap.String("reason", "protected/excluded tag"))
+			entry.Action = actionKept
+			entries = append(entries, entry)
+			continue
+		}
+		if policy.MinAge > 0 && now.Sub(*d.ImagePushedAt) < policy.MinAge {
+			logger.Info("keeping image", zap.String("repository", repoName), zap.Strings("tags", d.ImageTags), zap.String("reason", "younger than min-age"))
+			entry.Action = actionKept
+			entries = append(entries, entry)
+			continue
+		}
+		if kept < policy.Keep {
+			kept++
+			logger.Info("keeping image", zap.String("repository", repoName), zap.Strings("tags", d.ImageTags), zap.String("reason", "newest N"))
+			entry.Action = actionKept
+			entries = append(entries, entry)
+			continue
+		}
+
+		// d.ImageTags lists every tag that currently points at this digest,
+		// not just the one(s) that were classified as unused candidates: a
+		// digest can also carry a tag a consumer references (e.g. "prod"),
+		// which never became a candidate and must not be untagged as a
+		// side effect of deleting an unrelated unused alias of the same
+		// digest.
+		var deletableTags []string
+		for _, tag := range d.ImageTags {
+			if _, ok := byTag[tag]; ok {
+				deletableTags = append(deletableTags, tag)
+			}
+		}
+		if len(d.ImageTags) > 0 && len(deletableTags) == 0 {
+			logger.Info("keeping image", zap.String("repository", repoName), zap.Strings("tags", d.ImageTags), zap.String("reason", "other tag on this digest is in use"))
+			entry.Action = actionKept
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry.Action = deleteAction
+		entries = append(entries, entry)
+		if len(d.ImageTags) == 0 {
+			if d.ImageDigest != nil {
+				deletedDigests[*d.ImageDigest] = struct{}{}
+			}
+			toDelete = append(toDelete, types.ImageIdentifier{ImageDigest: d.ImageDigest})
+			continue
+		}
+		for _, tag := range deletableTags {
+			tag := tag
+			toDelete = append(toDelete, types.ImageIdentifier{ImageTag: &tag})
+		}
+		if d.ImageDigest != nil && len(deletableTags) == len(d.ImageTags) {
+			deletedDigests[*d.ImageDigest] = struct{}{}
+		}
+	}
+	return toDelete, deletedDigests, entries, nil
+}
+
+// describeImages returns the ECR image details (push time, size, tags) for
+// the given image IDs, following pagination.
+func describeImages(ctx context.Context, svc ecr.DescribeImagesAPIClient, repoName string, imageIDs []types.ImageIdentifier) (details []types.ImageDetail, err error) {
+	p := ecr.NewDescribeImagesPaginator(svc, &ecr.DescribeImagesInput{
+		RepositoryName: &repoName,
+		ImageIds:       imageIDs,
+	})
+	for p.HasMorePages() {
+		var op *ecr.DescribeImagesOutput
+		op, err = p.NextPage(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to describe images: %w", err)
+			return
+		}
+		details = append(details, op.ImageDetails...)
 	}
+	return
+}
 
+func deleteImages(ctx context.Context, cfg aws.Config, repoName string, imageIDs []types.ImageIdentifier) (err error) {
 	ecrService := ecr.NewFromConfig(cfg)
 	_, err = ecrService.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
 		RepositoryName: &repoName,
@@ -128,47 +514,85 @@ func deleteImages(ctx context.Context, cfg aws.Config, repoName string, tags []s
 	return err
 }
 
+// image is a single tagged or (when discovered with --prune-untagged)
+// untagged manifest in an ECR repository.
 type image struct {
-	Repo repo
-	URI  string
-	Tag  string
+	Repo   repo
+	URI    string
+	Tag    string
+	Digest string
+}
+
+// tagURI returns the repo:tag form of the image, or "" if it's untagged.
+func (img image) tagURI() string {
+	if img.Tag == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", img.Repo.URI, img.Tag)
+}
+
+// digestURI returns the repo@sha256:digest form of the image, or "" if the
+// digest isn't known.
+func (img image) digestURI() string {
+	if img.Digest == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s@%s", img.Repo.URI, img.Digest)
+}
+
+// imageIdentifier returns the ECR identifier to use when describing or
+// deleting this image: its tag if it has one, otherwise its digest.
+func (img image) imageIdentifier() types.ImageIdentifier {
+	if img.Tag != "" {
+		return types.ImageIdentifier{ImageTag: &img.Tag}
+	}
+	return types.ImageIdentifier{ImageDigest: &img.Digest}
+}
+
+// inUseConsumersForImage returns the consumers referencing img, matched by
+// either its tag or its digest, since consumers may pin either form.
+func inUseConsumersForImage(img image, inUseByImage map[string][]inUseBy) (result []inUseBy) {
+	if tagURI := img.tagURI(); tagURI != "" {
+		result = append(result, inUseByImage[tagURI]...)
+	}
+	if digestURI := img.digestURI(); digestURI != "" {
+		result = append(result, inUseByImage[digestURI]...)
+	}
+	return
 }
 
-func getAllImages(ctx context.Context, cfg aws.Config) (images []image, err error) {
+func getAllImages(ctx context.Context, cfg aws.Config, pruneUntagged bool) (images []image, err error) {
 	ecrService := ecr.NewFromConfig(cfg)
 
 	var repositories []repo
-	repositories, err = getRepositories(ctx, ecrService)
+	repositories, err = getRepositories(ctx, ecrService, cfg)
 	if err != nil {
 		err = fmt.Errorf("failed to get repositories: %w", err)
 		return
 	}
 
 	for _, repo := range repositories {
-		var tags []string
-		tags, err = getRepositoryImages(ctx, ecrService, repo.Name)
+		var repoImages []image
+		repoImages, err = getRepositoryImages(ctx, ecrService, repo, pruneUntagged)
 		if err != nil {
 			err = fmt.Errorf("failed to describe repositories: %w", err)
 			return
 		}
-		for _, tag := range tags {
-			images = append(images, image{
-				Repo: repo,
-				URI:  fmt.Sprintf("%s:%s", repo.URI, tag),
-				Tag:  tag,
-			})
-		}
+		images = append(images, repoImages...)
 	}
 
 	return
 }
 
+// repo identifies a single ECR repository and carries the aws.Config used
+// to reach the account/region it lives in, since a sweep may span several.
 type repo struct {
 	URI  string
 	Name string
+	Cfg  aws.Config
 }
 
-func getRepositories(ctx context.Context, svc *ecr.Client) (result []repo, err error) {
+func getRepositories(ctx context.Context, svc *ecr.Client, cfg aws.Config) (result []repo, err error) {
 	p := ecr.NewDescribeRepositoriesPaginator(svc, &ecr.DescribeRepositoriesInput{})
 	for p.HasMorePages() {
 		var op *ecr.DescribeRepositoriesOutput
@@ -178,15 +602,19 @@ func getRepositories(ctx context.Context, svc *ecr.Client) (result []repo, err e
 			return
 		}
 		for _, r := range op.Repositories {
-			result = append(result, repo{URI: *r.RepositoryUri, Name: *r.RepositoryName})
+			result = append(result, repo{URI: *r.RepositoryUri, Name: *r.RepositoryName, Cfg: cfg})
 		}
 	}
 	return
 }
 
-func getRepositoryImages(ctx context.Context, svc *ecr.Client, repositoryName string) (result []string, err error) {
+// getRepositoryImages lists the images in repo. Untagged manifests (e.g.
+// stale manifests left behind by a tag that's since been overwritten) are
+// only included when pruneUntagged is set, to preserve the tool's default
+// behaviour of leaving them alone.
+func getRepositoryImages(ctx context.Context, svc *ecr.Client, repo repo, pruneUntagged bool) (result []image, err error) {
 	p := ecr.NewListImagesPaginator(svc, &ecr.ListImagesInput{
-		RepositoryName: &repositoryName,
+		RepositoryName: &repo.Name,
 	})
 	for p.HasMorePages() {
 		var op *ecr.ListImagesOutput
@@ -197,82 +625,21 @@ func getRepositoryImages(ctx context.Context, svc *ecr.Client, repositoryName st
 		}
 		for _, id := range op.ImageIds {
 			if id.ImageTag != nil {
-				result = append(result, *id.ImageTag)
-			}
-		}
-	}
-	return
-}
-
-type inUseImageECS struct {
-	ImageName string
-	Image     string
-}
-
-func getInUseImages(ctx context.Context, cfg aws.Config) (images []inUseImageECS, err error) {
-	ecsService := ecs.NewFromConfig(cfg)
-
-	p := ecs.NewListTaskDefinitionsPaginator(ecsService, &ecs.ListTaskDefinitionsInput{})
-	for p.HasMorePages() {
-		var op *ecs.ListTaskDefinitionsOutput
-		op, err = p.NextPage(ctx)
-
-		if err != nil {
-			err = fmt.Errorf("failed to list task definitions: %w", err)
-			return
-		}
-
-		for _, arn := range op.TaskDefinitionArns {
-			output, err := ecsService.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: &arn})
-			if err != nil {
-				return nil, err
-			}
-			for _, containerDef := range output.TaskDefinition.ContainerDefinitions {
-				images = append(images, inUseImageECS{
-					ImageName: *containerDef.Name,
-					Image:     *containerDef.Image,
-				})
-			}
-		}
-	}
-
-	return
-}
-
-type inUseImageLambda struct {
-	FunctionName string
-	Container    string
-}
-
-func getInUseImagesLambda(ctx context.Context, cfg aws.Config) (inUseImages []inUseImageLambda, err error) {
-	lambdaService := lambda.NewFromConfig(cfg)
-
-	p := lambda.NewListFunctionsPaginator(lambdaService, &lambda.ListFunctionsInput{})
-	for p.HasMorePages() {
-		var op *lambda.ListFunctionsOutput
-		op, err = p.NextPage(ctx)
-		if err != nil {
-			err = fmt.Errorf("failed to list functions: %w", err)
-			return
-		}
-		for _, f := range op.Functions {
-			if string(f.PackageType) != "Image" {
+				img := image{Repo: repo, Tag: *id.ImageTag}
+				if id.ImageDigest != nil {
+					img.Digest = *id.ImageDigest
+				}
+				img.URI = img.tagURI()
+				result = append(result, img)
 				continue
 			}
-			var gfo *lambda.GetFunctionOutput
-			gfo, err = lambdaService.GetFunction(ctx, &lambda.GetFunctionInput{
-				FunctionName: f.FunctionName,
-			})
-			if err != nil {
-				err = fmt.Errorf("failed to get function %q: %w", *f.FunctionName, err)
-				return
+			if !pruneUntagged || id.ImageDigest == nil {
+				continue
 			}
-			inUseImages = append(inUseImages, inUseImageLambda{
-				FunctionName: *f.FunctionName,
-				Container:    *gfo.Code.ImageUri,
-			})
+			img := image{Repo: repo, Digest: *id.ImageDigest}
+			img.URI = img.digestURI()
+			result = append(result, img)
 		}
 	}
-
 	return
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"go.uber.org/zap"
+)
+
+// fakeDescribeImagesAPI answers DescribeImages from a canned set of
+// ImageDetails, ignoring the requested ImageIds so tests don't need to
+// replicate ECR's own tag/digest matching.
+type fakeDescribeImagesAPI struct {
+	details []types.ImageDetail
+}
+
+func (f fakeDescribeImagesAPI) DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	return &ecr.DescribeImagesOutput{ImageDetails: f.details}, nil
+}
+
+func strPtr(s string) *string        { return &s }
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	repo := repo{URI: "123.dkr.ecr.us-east-1.amazonaws.com/app", Name: "app"}
+	now := time.Date(2026, 7, 25, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		candidates []image
+		details    []types.ImageDetail
+		policy     retentionPolicy
+		wantDelete []string // tags/digests expected in toDelete
+	}{
+		{
+			name:       "protects tags matching protect pattern even if digest also has an unused tag",
+			candidates: []image{{Repo: repo, Tag: "old-ci-build", Digest: "sha256:aaa"}},
+			details: []types.ImageDetail{
+				{
+					ImageDigest:   strPtr("sha256:aaa"),
+					ImageTags:     []string{"prod", "old-ci-build"},
+					ImagePushedAt: timePtr(now.Add(-48 * time.Hour)),
+				},
+			},
+			policy:     retentionPolicy{ProtectTag: []*regexp.Regexp{regexp.MustCompile("^prod$")}},
+			wantDelete: nil,
+		},
+		{
+			name:       "only deletes the candidate tag, not an in-use sibling tag on the same digest",
+			candidates: []image{{Repo: repo, Tag: "old-ci-build", Digest: "sha256:aaa"}},
+			details: []types.ImageDetail{
+				{
+					ImageDigest:   strPtr("sha256:aaa"),
+					ImageTags:     []string{"prod", "old-ci-build"},
+					ImagePushedAt: timePtr(now.Add(-48 * time.Hour)),
+				},
+			},
+			policy:     retentionPolicy{},
+			wantDelete: []string{"old-ci-build"},
+		},
+		{
+			name:       "keeps images younger than min-age",
+			candidates: []image{{Repo: repo, Tag: "recent", Digest: "sha256:bbb"}},
+			details: []types.ImageDetail{
+				{
+					ImageDigest:   strPtr("sha256:bbb"),
+					ImageTags:     []string{"recent"},
+					ImagePushedAt: timePtr(now.Add(-1 * time.Hour)),
+				},
+			},
+			policy:     retentionPolicy{MinAge: 24 * time.Hour},
+			wantDelete: nil,
+		},
+		{
+			name: "keeps the newest N regardless of age",
+			candidates: []image{
+				{Repo: repo, Tag: "v2", Digest: "sha256:v2"},
+				{Repo: repo, Tag: "v1", Digest: "sha256:v1"},
+			},
+			details: []types.ImageDetail{
+				{ImageDigest: strPtr("sha256:v2"), ImageTags: []string{"v2"}, ImagePushedAt: timePtr(now.Add(-1 * time.Hour))},
+				{ImageDigest: strPtr("sha256:v1"), ImageTags: []string{"v1"}, ImagePushedAt: timePtr(now.Add(-2 * time.Hour))},
+			},
+			policy:     retentionPolicy{Keep: 1},
+			wantDelete: []string{"v1"},
+		},
+		{
+			name:       "deletes untagged manifests by digest",
+			candidates: []image{{Repo: repo, Digest: "sha256:ccc"}},
+			details: []types.ImageDetail{
+				{ImageDigest: strPtr("sha256:ccc"), ImagePushedAt: timePtr(now.Add(-48 * time.Hour))},
+			},
+			policy:     retentionPolicy{},
+			wantDelete: []string{"sha256:ccc"},
+		},
+		{
+			name:       "does not panic and keeps images with an unknown push time, since their age can't be judged",
+			candidates: []image{{Repo: repo, Tag: "unknown-age", Digest: "sha256:ddd"}},
+			details: []types.ImageDetail{
+				{ImageDigest: strPtr("sha256:ddd"), ImageTags: []string{"unknown-age"}},
+			},
+			policy:     retentionPolicy{MinAge: 24 * time.Hour},
+			wantDelete: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakeDescribeImagesAPI{details: tt.details}
+			toDelete, _, _, err := applyRetentionPolicy(context.Background(), svc, repo.Name, tt.candidates, tt.policy, now, true, zap.NewNop())
+			if err != nil {
+				t.Fatalf("applyRetentionPolicy: %v", err)
+			}
+			var got []string
+			for _, id := range toDelete {
+				switch {
+				case id.ImageTag != nil:
+					got = append(got, *id.ImageTag)
+				case id.ImageDigest != nil:
+					got = append(got, *id.ImageDigest)
+				}
+			}
+			if !equalUnordered(got, tt.wantDelete) {
+				t.Errorf("toDelete = %v, want %v", got, tt.wantDelete)
+			}
+		})
+	}
+}
+
+func equalUnordered(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[string]int{}
+	for _, v := range a {
+		seen[v]++
+	}
+	for _, v := range b {
+		seen[v]--
+	}
+	for _, count := range seen {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/a-h/pager"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"go.uber.org/zap"
+)
+
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIImageIndex      = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestList is the subset of a Docker manifest list / OCI image index
+// that we need to find the digests of the child manifests it references.
+type manifestList struct {
+	Manifests []struct {
+		Digest string `json:"digest"`
+	} `json:"manifests"`
+}
+
+// applyManifestAwareness expands manifest-list (multi-arch) candidates in
+// toDelete to also cover their orphaned child manifests, and protects any
+// child that's still referenced by a manifest list that isn't being
+// deleted, so that BatchDeleteImage can't orphan or break a multi-arch
+// image. survivingDigests is the set of digests in the repository that
+// aren't candidates for deletion; their manifests are fetched too; a
+// surviving image can itself be a manifest list, and its children must be
+// protected even though the index was never a deletion candidate.
+//
+// toDelete is kept and returned identifier-for-identifier rather than
+// rebuilt from a digest-keyed map: two distinct tags can share a digest
+// (e.g. two unused aliases of the same image), and collapsing on digest
+// would silently drop one of two legitimate deletes.
+func applyManifestAwareness(ctx context.Context, svc ecrBatchGetImageAPI, repoName string, toDelete []types.ImageIdentifier, survivingDigests map[string]struct{}, dryRun bool, logger *zap.Logger) (final []types.ImageIdentifier, err error) {
+	if len(toDelete) == 0 {
+		return toDelete, nil
+	}
+
+	deletingImages, err := batchGetImages(ctx, svc, repoName, toDelete)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image manifests: %w", err)
+	}
+
+	survivingIDs := make([]types.ImageIdentifier, 0, len(survivingDigests))
+	for digest := range survivingDigests {
+		digest := digest
+		survivingIDs = append(survivingIDs, types.ImageIdentifier{ImageDigest: &digest})
+	}
+	survivingImages, err := batchGetImages(ctx, svc, repoName, survivingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get surviving image manifests: %w", err)
+	}
+
+	allImages := make([]types.Image, 0, len(deletingImages)+len(survivingImages))
+	allImages = append(allImages, deletingImages...)
+	allImages = append(allImages, survivingImages...)
+
+	childParents := map[string][]string{}
+	for _, img := range allImages {
+		if img.ImageManifestMediaType == nil || img.ImageManifest == nil {
+			continue
+		}
+		if *img.ImageManifestMediaType != mediaTypeDockerManifestList && *img.ImageManifestMediaType != mediaTypeOCIImageIndex {
+			continue
+		}
+		if img.ImageId == nil || img.ImageId.ImageDigest == nil {
+			continue
+		}
+		parentDigest := *img.ImageId.ImageDigest
+
+		var parsed manifestList
+		if jsonErr := json.Unmarshal([]byte(*img.ImageManifest), &parsed); jsonErr != nil {
+			continue
+		}
+
+		children := make([]string, len(parsed.Manifests))
+		for i, m := range parsed.Manifests {
+			children[i] = m.Digest
+		}
+		if dryRun {
+			logger.Info("manifest index references children", zap.String("repository", repoName), zap.String("index", parentDigest), zap.Strings("children", children))
+		}
+
+		for _, child := range children {
+			childParents[child] = append(childParents[child], parentDigest)
+		}
+	}
+
+	// protected reports whether digest is a child of a manifest list that
+	// isn't itself being deleted, so BatchDeleteImage can't orphan it.
+	protected := func(digest string) bool {
+		for _, parent := range childParents[digest] {
+			if _, surviving := survivingDigests[parent]; surviving {
+				return true
+			}
+		}
+		return false
+	}
+
+	deletingDigests := map[string]struct{}{}
+	for _, img := range deletingImages {
+		if img.ImageId == nil || img.ImageId.ImageDigest == nil {
+			continue
+		}
+		digest := *img.ImageId.ImageDigest
+		deletingDigests[digest] = struct{}{}
+		if protected(digest) {
+			logger.Info("keeping image", zap.String("repository", repoName), zap.String("digest", digest), zap.String("reason", "referenced by a surviving manifest list"))
+			continue
+		}
+		final = append(final, *img.ImageId)
+	}
+
+	// Any child digest referenced only by a manifest list we're deleting,
+	// and not itself a surviving image, is orphaned and must go with it.
+	orphans := map[string]struct{}{}
+	for child := range childParents {
+		if _, already := deletingDigests[child]; already {
+			continue
+		}
+		if _, surviving := survivingDigests[child]; surviving {
+			continue
+		}
+		if protected(child) {
+			continue
+		}
+		orphans[child] = struct{}{}
+	}
+	for child := range orphans {
+		child := child
+		final = append(final, types.ImageIdentifier{ImageDigest: &child})
+	}
+
+	return final, nil
+}
+
+// ecrBatchGetImageAPI is the subset of *ecr.Client that batchGetImages
+// needs, so tests can supply a fake instead of hitting AWS.
+type ecrBatchGetImageAPI interface {
+	BatchGetImage(ctx context.Context, params *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+}
+
+// batchGetImages fetches the manifests for imageIDs, 100 at a time.
+func batchGetImages(ctx context.Context, svc ecrBatchGetImageAPI, repoName string, imageIDs []types.ImageIdentifier) (images []types.Image, err error) {
+	for idPage := range pager.Channel(imageIDs, 100) {
+		var op *ecr.BatchGetImageOutput
+		op, err = svc.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+			RepositoryName: &repoName,
+			ImageIds:       idPage,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to batch get images: %w", err)
+			return
+		}
+		images = append(images, op.Images...)
+	}
+	return
+}
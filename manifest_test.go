@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"go.uber.org/zap"
+)
+
+// fakeBatchGetImageAPI answers BatchGetImage by digest or tag from canned
+// maps, mirroring how ECR resolves any ImageIdentifier to its underlying
+// image.
+type fakeBatchGetImageAPI struct {
+	byDigest map[string]types.Image
+	byTag    map[string]types.Image
+}
+
+func (f fakeBatchGetImageAPI) BatchGetImage(ctx context.Context, params *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	var out []types.Image
+	for _, id := range params.ImageIds {
+		switch {
+		case id.ImageTag != nil:
+			if img, ok := f.byTag[*id.ImageTag]; ok {
+				out = append(out, img)
+			}
+		case id.ImageDigest != nil:
+			if img, ok := f.byDigest[*id.ImageDigest]; ok {
+				out = append(out, img)
+			}
+		}
+	}
+	return &ecr.BatchGetImageOutput{Images: out}, nil
+}
+
+func manifestListJSON(children ...string) string {
+	s := `{"manifests":[`
+	for i, c := range children {
+		if i > 0 {
+			s += ","
+		}
+		s += `{"digest":"` + c + `"}`
+	}
+	s += `]}`
+	return s
+}
+
+func imageIdentifier(digest string) types.ImageIdentifier {
+	return types.ImageIdentifier{ImageDigest: &digest}
+}
+
+func manifestListImage(digest, manifest string) types.Image {
+	mediaType := mediaTypeOCIImageIndex
+	return types.Image{
+		ImageId:                &types.ImageIdentifier{ImageDigest: &digest},
+		ImageManifest:          &manifest,
+		ImageManifestMediaType: &mediaType,
+	}
+}
+
+func plainImage(digest string) types.Image {
+	return types.Image{ImageId: &types.ImageIdentifier{ImageDigest: &digest}}
+}
+
+// taggedImage mimics how ECR echoes back both the requested tag and the
+// digest it resolves to in a BatchGetImage response.
+func taggedImage(tag, digest string) types.Image {
+	return types.Image{ImageId: &types.ImageIdentifier{ImageTag: &tag, ImageDigest: &digest}}
+}
+
+func TestApplyManifestAwareness(t *testing.T) {
+	t.Run("protects children of a surviving parent index", func(t *testing.T) {
+		parent := "sha256:parent"
+		child1 := "sha256:child1"
+		child2 := "sha256:child2"
+
+		svc := fakeBatchGetImageAPI{byDigest: map[string]types.Image{
+			parent: manifestListImage(parent, manifestListJSON(child1, child2)),
+			child1: plainImage(child1),
+			child2: plainImage(child2),
+		}}
+
+		toDelete := []types.ImageIdentifier{imageIdentifier(child1), imageIdentifier(child2)}
+		survivingDigests := map[string]struct{}{parent: {}}
+
+		final, err := applyManifestAwareness(context.Background(), svc, "app", toDelete, survivingDigests, true, zap.NewNop())
+		if err != nil {
+			t.Fatalf("applyManifestAwareness: %v", err)
+		}
+		if len(final) != 0 {
+			t.Errorf("expected no images to survive as deletable, got %v", digestsOf(final))
+		}
+	})
+
+	t.Run("expands an orphaned parent's children into the deletion set", func(t *testing.T) {
+		parent := "sha256:oldparent"
+		child1 := "sha256:oldchild1"
+		child2 := "sha256:oldchild2"
+
+		svc := fakeBatchGetImageAPI{byDigest: map[string]types.Image{
+			parent: manifestListImage(parent, manifestListJSON(child1, child2)),
+		}}
+
+		toDelete := []types.ImageIdentifier{imageIdentifier(parent)}
+
+		final, err := applyManifestAwareness(context.Background(), svc, "app", toDelete, map[string]struct{}{}, true, zap.NewNop())
+		if err != nil {
+			t.Fatalf("applyManifestAwareness: %v", err)
+		}
+		got := digestsOf(final)
+		want := map[string]bool{parent: true, child1: true, child2: true}
+		if len(got) != len(want) {
+			t.Fatalf("final = %v, want digests %v", got, want)
+		}
+		for _, d := range got {
+			if !want[d] {
+				t.Errorf("unexpected digest %q in final", d)
+			}
+		}
+	})
+
+	t.Run("preserves both tags when two unused aliases share a digest", func(t *testing.T) {
+		digest := "sha256:shared"
+		svc := fakeBatchGetImageAPI{byTag: map[string]types.Image{
+			"old1": taggedImage("old1", digest),
+			"old2": taggedImage("old2", digest),
+		}}
+
+		toDelete := []types.ImageIdentifier{{ImageTag: strPtr("old1")}, {ImageTag: strPtr("old2")}}
+		final, err := applyManifestAwareness(context.Background(), svc, "app", toDelete, map[string]struct{}{}, true, zap.NewNop())
+		if err != nil {
+			t.Fatalf("applyManifestAwareness: %v", err)
+		}
+		if len(final) != 2 {
+			t.Fatalf("final = %v, want 2 entries (old1 and old2 both survive as candidates)", final)
+		}
+		gotTags := map[string]bool{}
+		for _, id := range final {
+			if id.ImageTag != nil {
+				gotTags[*id.ImageTag] = true
+			}
+		}
+		if !gotTags["old1"] || !gotTags["old2"] {
+			t.Errorf("expected both old1 and old2 in final, got tags %v", gotTags)
+		}
+	})
+
+	t.Run("leaves non-manifest-list candidates untouched", func(t *testing.T) {
+		digest := "sha256:plain"
+		svc := fakeBatchGetImageAPI{byDigest: map[string]types.Image{digest: plainImage(digest)}}
+
+		toDelete := []types.ImageIdentifier{imageIdentifier(digest)}
+		final, err := applyManifestAwareness(context.Background(), svc, "app", toDelete, map[string]struct{}{}, true, zap.NewNop())
+		if err != nil {
+			t.Fatalf("applyManifestAwareness: %v", err)
+		}
+		got := digestsOf(final)
+		if len(got) != 1 || got[0] != digest {
+			t.Errorf("final = %v, want [%s]", got, digest)
+		}
+	})
+}
+
+func digestsOf(ids []types.ImageIdentifier) []string {
+	var out []string
+	for _, id := range ids {
+		if id.ImageDigest != nil {
+			out = append(out, *id.ImageDigest)
+		}
+	}
+	return out
+}
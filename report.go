@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	actionInUse       = "in_use"
+	actionKept        = "kept"
+	actionDeleted     = "deleted"
+	actionWouldDelete = "would_delete"
+)
+
+// report is the structured summary of a run: what was found, what was kept
+// or deleted, and why, so a CI pipeline can gate on it or a human can
+// review it before a destructive run.
+type report struct {
+	DryRun       bool               `json:"dry_run"`
+	ScannedAt    time.Time          `json:"scanned_at"`
+	Repositories []repositoryReport `json:"repositories"`
+	Images       []imageReport      `json:"images"`
+}
+
+// repositoryReport summarises a single repository's sweep.
+type repositoryReport struct {
+	Name        string `json:"name"`
+	TotalImages int    `json:"total_images"`
+	InUse       int    `json:"in_use"`
+	Unused      int    `json:"unused"`
+	Deleted     int    `json:"deleted"`
+	Errors      int    `json:"errors"`
+}
+
+// inUseBy identifies one consumer that references an image.
+type inUseBy struct {
+	Service     string `json:"service"`
+	ResourceARN string `json:"resource_arn"`
+}
+
+// imageReport describes the fate of a single image. PushedAt and SizeBytes
+// are only known for images we called DescribeImages on, which today means
+// unused candidates considered by the retention policy; in-use images are
+// reported by identity and consumer alone.
+type imageReport struct {
+	URI       string     `json:"uri"`
+	Tag       string     `json:"tag,omitempty"`
+	Digest    string     `json:"digest,omitempty"`
+	PushedAt  *time.Time `json:"pushed_at,omitempty"`
+	SizeBytes int64      `json:"size_bytes,omitempty"`
+	InUseBy   []inUseBy  `json:"in_use_by,omitempty"`
+	Action    string     `json:"action"`
+}
+
+// writeReport renders rep to w in the given format: "json", "csv", or
+// "text".
+func writeReport(w io.Writer, format string, rep report) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "csv":
+		return writeReportCSV(w, rep)
+	case "text":
+		return writeReportText(w, rep)
+	default:
+		return fmt.Errorf("invalid output format %q, must be json, csv, or text", format)
+	}
+}
+
+func writeReportCSV(w io.Writer, rep report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"uri", "tag", "digest", "pushed_at", "size_bytes", "in_use_by", "action"}); err != nil {
+		return err
+	}
+	for _, img := range rep.Images {
+		var pushedAt string
+		if img.PushedAt != nil {
+			pushedAt = img.PushedAt.Format(time.RFC3339)
+		}
+		var sizeBytes string
+		if img.SizeBytes != 0 {
+			sizeBytes = strconv.FormatInt(img.SizeBytes, 10)
+		}
+		inUseBy := make([]string, len(img.InUseBy))
+		for i, c := range img.InUseBy {
+			inUseBy[i] = fmt.Sprintf("%s:%s", c.Service, c.ResourceARN)
+		}
+		row := []string{img.URI, img.Tag, img.Digest, pushedAt, sizeBytes, strings.Join(inUseBy, ";"), img.Action}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeReportText(w io.Writer, rep report) error {
+	mode := "Would delete"
+	if !rep.DryRun {
+		mode = "Deleted"
+	}
+	for _, r := range rep.Repositories {
+		_, err := fmt.Fprintf(w, "%s - %d images, %d in use, %d unused, %d %s, %d errors\n",
+			r.Name, r.TotalImages, r.InUse, r.Unused, r.Deleted, strings.ToLower(mode), r.Errors)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReportCSV(t *testing.T) {
+	pushedAt := time.Date(2026, 7, 25, 12, 0, 0, 0, time.UTC)
+	rep := report{
+		DryRun: true,
+		Images: []imageReport{
+			{
+				URI:       "123.dkr.ecr.us-east-1.amazonaws.com/app:v1",
+				Tag:       "v1",
+				Digest:    "sha256:aaa",
+				PushedAt:  &pushedAt,
+				SizeBytes: 1024,
+				InUseBy:   []inUseBy{{Service: "ecs", ResourceARN: "arn:aws:ecs:task/1"}},
+				Action:    actionInUse,
+			},
+			{
+				URI:    "123.dkr.ecr.us-east-1.amazonaws.com/app:old",
+				Tag:    "old",
+				Digest: "sha256:bbb",
+				Action: actionWouldDelete,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeReportCSV(&buf, rep); err != nil {
+		t.Fatalf("writeReportCSV: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (header + 2 rows): %q", len(lines), buf.String())
+	}
+	if lines[0] != "uri,tag,digest,pushed_at,size_bytes,in_use_by,action" {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	want := "123.dkr.ecr.us-east-1.amazonaws.com/app:v1,v1,sha256:aaa,2026-07-25T12:00:00Z,1024,ecs:arn:aws:ecs:task/1,in_use"
+	if lines[1] != want {
+		t.Errorf("row 1 = %q, want %q", lines[1], want)
+	}
+	want = "123.dkr.ecr.us-east-1.amazonaws.com/app:old,old,sha256:bbb,,,,would_delete"
+	if lines[2] != want {
+		t.Errorf("row 2 = %q, want %q", lines[2], want)
+	}
+}
+
+func TestWriteReportText(t *testing.T) {
+	tests := []struct {
+		name string
+		rep  report
+		want string
+	}{
+		{
+			name: "dry run",
+			rep: report{
+				DryRun: true,
+				Repositories: []repositoryReport{
+					{Name: "app", TotalImages: 10, InUse: 6, Unused: 4, Deleted: 3, Errors: 0},
+				},
+			},
+			want: "app - 10 images, 6 in use, 4 unused, 3 would delete, 0 errors\n",
+		},
+		{
+			name: "live run",
+			rep: report{
+				DryRun: false,
+				Repositories: []repositoryReport{
+					{Name: "app", TotalImages: 10, InUse: 6, Unused: 4, Deleted: 3, Errors: 1},
+				},
+			},
+			want: "app - 10 images, 6 in use, 4 unused, 3 deleted, 1 errors\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeReportText(&buf, tt.rep); err != nil {
+				t.Fatalf("writeReportText: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
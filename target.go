@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// target describes a single AWS account to sweep: the role to assume to get
+// there, and the regions within it to inspect. AccountID is used only for
+// labelling output and keying the report; it isn't passed to STS.
+type target struct {
+	AccountID string   `yaml:"account_id"`
+	RoleARN   string   `yaml:"role_arn"`
+	Regions   []string `yaml:"regions"`
+}
+
+// cleanupConfig is the shape of the --config file: the accounts (and
+// regions within each) to sweep in a single run.
+type cleanupConfig struct {
+	Targets []target `yaml:"targets"`
+}
+
+// loadConfig reads and parses a cleanup config file. YAML is used for
+// parsing since it's a superset of JSON, so a --config file may be written
+// in either format.
+func loadConfig(path string) (cfg cleanupConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read config file %q: %w", path, err)
+		return
+	}
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		err = fmt.Errorf("failed to parse config file %q: %w", path, err)
+		return
+	}
+	return
+}